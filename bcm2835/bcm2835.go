@@ -16,8 +16,8 @@ package bcm2835
 
 import (
 	"errors"
+	"github.com/apparentlymart/go-bcm2835io/gpiocdev"
 	"github.com/apparentlymart/go-gpio/gpio"
-	"github.com/apparentlymart/go-linuxgpio/linuxgpio"
 	"os"
 	"reflect"
 	"syscall"
@@ -25,6 +25,10 @@ import (
 	"unsafe"
 )
 
+// gpioChipPath is the character device backing all GpioPin.MakeLinuxGpioNode
+// calls. The BCM2835's own 54 GPIOs are always gpiochip0 on Raspberry Pi OS.
+const gpioChipPath = "/dev/gpiochip0"
+
 const (
 	peripheralBase = 0x20000000
 	gpioBase       = peripheralBase + 0x200000
@@ -45,11 +49,35 @@ type GpioPin interface {
 	// Number returns the GPIO number that this instance controls.
 	Number() int
 
-	// MakeLinuxGpioNode returns a linuxgpio.Node representing the Linux
-	// sysfs endpoint corresponding to this GPIO. This allows access to
-	// capabilities that are exposed via sysfs and that are not yet supported
-	// by this library, such as edge waiting.
-	MakeLinuxGpioNode() (node linuxgpio.Node)
+	// MakeLinuxGpioNode requests ownership of this GPIO from the Linux
+	// gpiochip character device and returns a gpiocdev.Node representing
+	// it. This allows access to capabilities that are not yet supported
+	// by this library's own mmap-based register access, such as edge
+	// waiting. The returned Node owns the line exclusively until its
+	// Close method is called.
+	MakeLinuxGpioNode(flags gpiocdev.LineFlags) (node gpiocdev.Node, err error)
+
+	// SetAltFunction routes the pin to one of its alternate peripheral
+	// functions (0 through 5), as assigned by the BCM2835's pin multiplexer.
+	// Which peripheral each alt function corresponds to is pin-specific and
+	// documented in the BCM2835 peripherals manual; for example, ALT0 on
+	// GPIO12 and ALT5 on GPIO18 both select the PWM0 output.
+	//
+	// Passing a negative alt number is not valid; use SetDirection to
+	// return a pin to plain input or output mode instead.
+	SetAltFunction(alt int) error
+
+	// Function returns what this pin's GPFSELn field currently selects:
+	// In, Out, or one of the six alternate peripheral functions.
+	Function() Function
+
+	// SetFunction routes this pin to f. It is equivalent to calling
+	// SetDirection for In/Out, or SetAltFunction for Alt0 through Alt5.
+	SetFunction(f Function) error
+
+	// Describe returns this pin's Pin descriptor, giving its conventional
+	// name, default pull, and alternate function table.
+	Describe() Pin
 }
 
 // Manager is the main access point for BCM2835 I/O peripherals. Obtain a
@@ -58,13 +86,36 @@ type Manager interface {
 	// GpioPin returns the GpioPin for the GPIO with the given number.
 	GpioPin(number int) (pin GpioPin)
 
+	// PWMChannel returns the PWMChannel for hardware PWM channel n, which
+	// must be 0 or 1. Both channels are routed to a GPIO's ALT0 or ALT5
+	// function depending on the pin; see SetAltFunction on GpioPin.
+	PWMChannel(n int) (channel PWMChannel)
+
+	// PulseStream returns a new PulseStream for emitting DMA-paced GPIO
+	// pulse sequences. Each call opens the VideoCore mailbox interface
+	// again; the returned PulseStream's Close releases it.
+	PulseStream() (stream PulseStream, err error)
+
+	// SetPinsMask sets and clears GPIO 0-53 with one set and one clear
+	// register write per 32-pin half, rather than one write per pin.
+	SetPinsMask(setMask, clearMask uint64) error
+
+	// ReadPinsMask reads the level of GPIO 0-53 as one bit per pin.
+	ReadPinsMask() uint64
+
+	// PinBank returns a PinBank addressing the given GPIO numbers as a
+	// single unit.
+	PinBank(pins []int) (bank PinBank, err error)
+
 	// Close frees the resources allocated when opening this manager.
 	// Once this is called, this manager and any child objects created from it
 	// may no longer be used and references to them should be discarded.
 	Close() error
 }
 
-type manager struct{}
+type manager struct {
+	realtimePriority *int
+}
 
 type gpioPin int
 
@@ -72,11 +123,14 @@ type gpioPin int
 // Only one Manager can be open at once, so it should be opened early
 // in the execution of a program and used to gain access to specific
 // peripheral drivers.
-func Open() (Manager, error) {
+func Open(opts ...Option) (Manager, error) {
 	if mem8 != nil {
 		return nil, errors.New("BCM2835 already open")
 	}
 	mgr := manager{}
+	for _, opt := range opts {
+		opt(&mgr)
+	}
 
 	file, err := os.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, 0)
 	if err != nil {
@@ -95,20 +149,60 @@ func Open() (Manager, error) {
 		return nil, err
 	}
 
-	// Convert mem8 into a uint32 slice by tinkering with its guts.
+	mem32 = bytesToWords(mem8)
+
+	if err := mapPWMAndClock(int(file.Fd())); err != nil {
+		syscall.Munmap(mem8)
+		mem8 = nil
+		mem32 = nil
+		return nil, err
+	}
+
+	if err := mapDMA(int(file.Fd())); err != nil {
+		unmapPWMAndClock()
+		syscall.Munmap(mem8)
+		mem8 = nil
+		mem32 = nil
+		return nil, err
+	}
+
+	if mgr.realtimePriority != nil {
+		if err := applyRealtimePriority(*mgr.realtimePriority); err != nil {
+			unmapDMA()
+			unmapPWMAndClock()
+			syscall.Munmap(mem8)
+			mem8 = nil
+			mem32 = nil
+			return nil, err
+		}
+	}
+
+	return &mgr, nil
+}
+
+// bytesToWords reinterprets a byte slice obtained from syscall.Mmap as a
+// uint32 slice covering the same memory, so that register accesses don't
+// need to manually compute byte offsets and endianness-sensitive shifts.
+func bytesToWords(mem8 []uint8) []uint32 {
 	rawMem32 := *(*reflect.SliceHeader)(unsafe.Pointer(&mem8))
 	rawMem32.Len /= 4 // four bytes per uint32
 	rawMem32.Cap /= 4
 
-	mem32 = *(*[]uint32)(unsafe.Pointer(&rawMem32))
-
-	return &mgr, nil
+	return *(*[]uint32)(unsafe.Pointer(&rawMem32))
 }
 
 // Close frees global resources associated with this package, invalidating
 // the Manager. Callers must call Open to obtain a new Manager if devices need
 // to be accessed again.
 func (mgr *manager) Close() error {
+	if err := unmapDMA(); err != nil {
+		return err
+	}
+
+	if err := unmapPWMAndClock(); err != nil {
+		return err
+	}
+
 	err := syscall.Munmap(mem8)
 	if err != nil {
 		return err
@@ -124,10 +218,9 @@ func (mgr *manager) GpioPin(number int) GpioPin {
 	return gpioPin(number)
 }
 
-// MakeLinuxGpioNode constructs a linuxgpio.Node object referring to the
-// same GPIO.
-func (pin gpioPin) MakeLinuxGpioNode() linuxgpio.Node {
-	return linuxgpio.MakeNode(int(pin))
+// MakeLinuxGpioNode requests this GPIO's line from /dev/gpiochip0.
+func (pin gpioPin) MakeLinuxGpioNode(flags gpiocdev.LineFlags) (gpiocdev.Node, error) {
+	return gpiocdev.RequestLine(gpioChipPath, int(pin), flags)
 }
 
 func (pin gpioPin) Number() int {
@@ -194,15 +287,39 @@ func (pin gpioPin) SetDirection(direction gpio.Direction) error {
 	return nil
 }
 
+// altFunctionSelectValues maps alt function numbers 0 through 5 to the
+// three-bit values used in GPFSELn to select them. Alt functions are not
+// numbered contiguously with In/Out in the register encoding, so this
+// table exists to hide that oddity from callers.
+var altFunctionSelectValues = [6]uint32{4, 5, 6, 7, 3, 2}
+
+func (pin gpioPin) SetAltFunction(alt int) error {
+	if alt < 0 || alt > 5 {
+		return errors.New("alt function number must be between 0 and 5")
+	}
+
+	number := uint32(pin)
+
+	selOffset := number / 10
+	selBit := (number % 10) * 3
+
+	value := altFunctionSelectValues[alt] << selBit
+	mask := ^uint32(7 << selBit)
+
+	mem32[selOffset] = (mem32[selOffset] & mask) | value
+
+	return nil
+}
+
 func (pin gpioPin) SetValue(value gpio.Value) error {
 	number := uint32(pin)
 
 	offset := number / 32
 	bit := number % 32
 	if value == gpio.Low {
-		offset += 10 // clear registers start at 10
+		offset += gpclr0Offset
 	} else {
-		offset += 7 // set registers start at 7
+		offset += gpset0Offset
 	}
 
 	mem32[offset] = 1 << bit
@@ -212,8 +329,7 @@ func (pin gpioPin) SetValue(value gpio.Value) error {
 func (pin gpioPin) Value() (gpio.Value, error) {
 	number := uint32(pin)
 
-	// value registers start at 13, with one bit per GPIO
-	offset := (number / 32) + 13
+	offset := (number / 32) + gplev0Offset
 	bit := number % 32
 
 	if (mem32[offset] & (1 << bit)) != 0 {
@@ -224,5 +340,5 @@ func (pin gpioPin) Value() (gpio.Value, error) {
 }
 
 func shortWait() {
-	time.Sleep(time.Microsecond)
+	Nanospin(time.Microsecond)
 }
@@ -0,0 +1,78 @@
+// +build linux
+
+package bcm2835
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/apparentlymart/go-gpio/gpio"
+)
+
+// Function identifies what a GPIO's three-bit GPFSELn field currently
+// selects it to do: plain input or output, or one of six alternate
+// peripheral routings.
+type Function int
+
+const (
+	In Function = iota
+	Out
+	Alt0
+	Alt1
+	Alt2
+	Alt3
+	Alt4
+	Alt5
+)
+
+func (f Function) String() string {
+	switch f {
+	case In:
+		return "In"
+	case Out:
+		return "Out"
+	case Alt0, Alt1, Alt2, Alt3, Alt4, Alt5:
+		return "Alt" + strconv.Itoa(int(f-Alt0))
+	default:
+		return "Invalid"
+	}
+}
+
+// Function returns what this pin's GPFSELn field currently selects.
+func (pin gpioPin) Function() Function {
+	number := uint32(pin)
+	selOffset := number / 10
+	selBit := (number % 10) * 3
+
+	raw := (mem32[selOffset] >> selBit) & 7
+	switch raw {
+	case 0:
+		return In
+	case 1:
+		return Out
+	default:
+		for i, v := range altFunctionSelectValues {
+			if v == raw {
+				return Alt0 + Function(i)
+			}
+		}
+		// Not reachable: the three-bit field only has eight possible
+		// values and we've covered all of them above.
+		return In
+	}
+}
+
+// SetFunction routes this pin to f, which may be In, Out, or one of the
+// six alternate peripheral functions.
+func (pin gpioPin) SetFunction(f Function) error {
+	switch {
+	case f == In:
+		return pin.SetDirection(gpio.In)
+	case f == Out:
+		return pin.SetDirection(gpio.Out)
+	case f >= Alt0 && f <= Alt5:
+		return pin.SetAltFunction(int(f - Alt0))
+	default:
+		return errors.New("invalid Function value")
+	}
+}
@@ -0,0 +1,155 @@
+// +build linux
+
+package bcm2835
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func openDevMem() (*os.File, error) {
+	return os.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, 0)
+}
+
+var dmaMem32 []uint32
+
+func mapDMA(fd int) error {
+	mem8, err := syscall.Mmap(
+		fd,
+		dmaBase+dmaChannel*dmaChannelLen,
+		dmaChannelLen,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return err
+	}
+
+	dmaMem8 = mem8
+	dmaMem32 = bytesToWords(mem8)
+	return nil
+}
+
+var dmaMem8 []uint8
+
+func unmapDMA() error {
+	if dmaMem8 == nil {
+		return nil
+	}
+
+	// Abort any in-flight PulseStream chain so it stops toggling its GPIO
+	// once the Manager is closed.
+	dmaMem32[dmaCsOffset] = dmaCsReset
+
+	if err := syscall.Munmap(dmaMem8); err != nil {
+		return err
+	}
+	dmaMem8 = nil
+	dmaMem32 = nil
+	return nil
+}
+
+// mailboxProperty sends a single-tag property request to the VideoCore
+// firmware via the /dev/vcio mailbox interface and returns the response
+// words that follow the tag's header.
+func (s *pulseStream) mailboxProperty(tag uint32, args ...uint32) ([]uint32, error) {
+	// Buffer layout, all little-endian uint32s: overall size, request
+	// code, tag, tag value buffer size, tag request/response size, then
+	// the value buffer itself, followed by a zero end tag.
+	valueWords := len(args)
+	if valueWords < 4 {
+		valueWords = 4 // mem_alloc/mem_lock/mem_free all fit in 4 words
+	}
+
+	bufLen := 6 + valueWords + 1
+	buf := make([]uint32, bufLen)
+	buf[0] = uint32(bufLen * 4)
+	buf[1] = 0 // process request
+	buf[2] = tag
+	buf[3] = uint32(valueWords * 4)
+	buf[4] = uint32(len(args) * 4)
+	for i, a := range args {
+		buf[5+i] = a
+	}
+	buf[bufLen-1] = 0 // end tag
+
+	raw := make([]byte, bufLen*4)
+	for i, w := range buf {
+		binary.LittleEndian.PutUint32(raw[i*4:], w)
+	}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		s.mbox.Fd(),
+		uintptr(iocMboxProperty),
+		uintptr(unsafe.Pointer(&raw[0])),
+	)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	for i := range buf {
+		buf[i] = binary.LittleEndian.Uint32(raw[i*4:])
+	}
+	if buf[1] != 0x80000000 {
+		return nil, errors.New("mailbox property request failed")
+	}
+
+	return buf[5 : 5+valueWords], nil
+}
+
+func (s *pulseStream) allocateDMAMemory(size uint32) (*dmaAllocation, error) {
+	// Round up to a whole number of 4K pages, as required by mem_alloc.
+	size = (size + 4095) &^ 4095
+
+	resp, err := s.mailboxProperty(mailboxMemAllocTag, size, 4096, mailboxMemFlags)
+	if err != nil {
+		return nil, err
+	}
+	mboxHandle := resp[0]
+
+	resp, err = s.mailboxProperty(mailboxMemLockTag, mboxHandle)
+	if err != nil {
+		return nil, err
+	}
+	busAddr := resp[0]
+
+	memFile, err := openDevMem()
+	if err != nil {
+		return nil, err
+	}
+	defer memFile.Close()
+
+	mem, err := syscall.Mmap(
+		int(memFile.Fd()),
+		int64(busAddr&^0xC0000000),
+		int(size),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dmaAllocation{
+		mboxHandle: mboxHandle,
+		busAddr:    busAddr,
+		size:       size,
+		mem:        mem,
+	}, nil
+}
+
+func (s *pulseStream) freeDMAMemory(alloc *dmaAllocation) error {
+	if err := syscall.Munmap(alloc.mem); err != nil {
+		return err
+	}
+
+	if _, err := s.mailboxProperty(mailboxMemFreeTag, alloc.mboxHandle); err != nil {
+		return err
+	}
+
+	return nil
+}
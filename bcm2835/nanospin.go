@@ -0,0 +1,69 @@
+// +build linux
+
+package bcm2835
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Nanospin busy-loops on the monotonic clock until d has elapsed, without
+// making any syscalls or yielding to the Go scheduler. Bit-banged protocols
+// that need delays shorter than the scheduler's wake-up granularity (such
+// as the pull-up/pull-down sequence, 1-Wire, or a software SPI/WS2812
+// fallback) should use this instead of time.Sleep, which in practice tends
+// to oversleep by tens of microseconds.
+//
+// Nanospin burns one CPU core for its entire duration, so it is only
+// appropriate for short delays. Combine it with RealtimePriority if even
+// the occasional preemption during the spin is unacceptable.
+func Nanospin(d time.Duration) {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+	}
+}
+
+// Option configures optional behavior when opening a Manager. See
+// RealtimePriority.
+type Option func(*manager)
+
+// RealtimePriority makes Open lock the goroutine that calls Open to its
+// current OS thread and raise that thread to the SCHED_FIFO real-time
+// scheduling policy at the given priority (1-99; higher runs sooner), so
+// that Nanospin delays and other latency-sensitive bit-banging aren't
+// interrupted by the Go scheduler or by other processes. The goroutine that
+// calls Open must therefore be the one driving the time-sensitive code, since it will not migrate to
+// another OS thread for the lifetime of the Manager.
+//
+// This typically requires the process to be running as root, or to hold
+// CAP_SYS_NICE.
+func RealtimePriority(prio int) Option {
+	return func(mgr *manager) {
+		mgr.realtimePriority = &prio
+	}
+}
+
+const schedFIFO = 1
+
+type schedParam struct {
+	priority int32
+}
+
+func applyRealtimePriority(prio int) error {
+	runtime.LockOSThread()
+
+	param := schedParam{priority: int32(prio)}
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_SCHED_SETSCHEDULER,
+		0, // the calling thread
+		uintptr(schedFIFO),
+		uintptr(unsafe.Pointer(&param)),
+	)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
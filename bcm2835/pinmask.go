@@ -0,0 +1,95 @@
+// +build linux
+
+package bcm2835
+
+import "errors"
+
+// Word offsets of the GPSET/GPCLR/GPLEV register pairs covering GPIO 0-53.
+const (
+	gpset0Offset = 7
+	gpset1Offset = 8
+	gpclr0Offset = 10
+	gpclr1Offset = 11
+	gplev0Offset = 13
+	gplev1Offset = 14
+)
+
+// SetPinsMask sets every GPIO with a set bit in setMask high and every GPIO
+// with a set bit in clearMask low, covering GPIO 0-53, in a single pair of
+// register writes per 32-pin half rather than one write per pin. Bits set
+// in both masks are undefined; callers should not set the same bit in both.
+func (mgr *manager) SetPinsMask(setMask, clearMask uint64) error {
+	mem32[gpset0Offset] = uint32(setMask)
+	mem32[gpset1Offset] = uint32(setMask >> 32)
+	mem32[gpclr0Offset] = uint32(clearMask)
+	mem32[gpclr1Offset] = uint32(clearMask >> 32)
+	return nil
+}
+
+// ReadPinsMask reads the current level of every GPIO 0-53 as one bit per
+// pin, in two register reads rather than one per pin.
+func (mgr *manager) ReadPinsMask() uint64 {
+	low := uint64(mem32[gplev0Offset])
+	high := uint64(mem32[gplev1Offset])
+	return low | (high << 32)
+}
+
+// PinBank addresses a fixed, caller-chosen group of GPIOs as a single unit,
+// so that e.g. a seven-segment display or a parallel bus can be refreshed
+// with one masked register write instead of one SetValue call per segment.
+type PinBank interface {
+	// SetValues sets each of this bank's pins according to the
+	// corresponding bit of values (bit i controls the pin at Pins()[i]),
+	// in a single SetPinsMask call.
+	SetValues(values uint64) error
+
+	// Pins returns the GPIO numbers that make up this bank, in the order
+	// their bits appear in SetValues.
+	Pins() []int
+}
+
+type pinBank struct {
+	mgr  *manager
+	pins []int
+}
+
+// PinBank returns a PinBank covering the given GPIO numbers, which must
+// each be in the range 0-53 and must not repeat.
+func (mgr *manager) PinBank(pins []int) (PinBank, error) {
+	seen := make(map[int]bool, len(pins))
+	for _, n := range pins {
+		if n < 0 || n > 53 {
+			return nil, errors.New("GPIO number out of range 0-53")
+		}
+		if seen[n] {
+			return nil, errors.New("PinBank pins must not repeat")
+		}
+		seen[n] = true
+	}
+
+	bank := make([]int, len(pins))
+	copy(bank, pins)
+
+	return &pinBank{mgr: mgr, pins: bank}, nil
+}
+
+func (b *pinBank) Pins() []int {
+	pins := make([]int, len(b.pins))
+	copy(pins, b.pins)
+	return pins
+}
+
+func (b *pinBank) SetValues(values uint64) error {
+	var setMask, clearMask uint64
+
+	for i, pin := range b.pins {
+		bit := uint64(1) << uint(pin)
+		if values&(1<<uint(i)) != 0 {
+			setMask |= bit
+		} else {
+			clearMask |= bit
+		}
+	}
+
+	return b.mgr.SetPinsMask(setMask, clearMask)
+}
@@ -0,0 +1,125 @@
+// +build linux
+
+package bcm2835
+
+// Pull identifies a GPIO's pull-up/pull-down resistor state.
+type Pull int
+
+const (
+	PullNone Pull = iota
+	PullDown
+	PullUp
+)
+
+// Pin describes one of the BCM2835's 54 GPIOs: its conventional name, the
+// pull state it powers up with, and which peripheral each of its six
+// alternate functions routes to. An empty AltFunctions entry means that
+// alt function is reserved or has no documented use for that pin.
+//
+// Use a GpioPin's Describe method to obtain its Pin (ByName and All look
+// up GpioPins, from which Describe gives the Pin); the data here comes from the alternate function table in the BCM2835 ARM
+// peripherals manual.
+type Pin struct {
+	Name         string
+	Number       int
+	DefaultPull  Pull
+	AltFunctions [6]string
+}
+
+var pinTable = []Pin{
+	{Name: "GPIO0", Number: 0, DefaultPull: PullUp, AltFunctions: [6]string{"I2C0_SDA", "SA5", "PCLK", "", "", ""}},
+	{Name: "GPIO1", Number: 1, DefaultPull: PullUp, AltFunctions: [6]string{"I2C0_SCL", "SA4", "DE", "", "", ""}},
+	{Name: "GPIO2", Number: 2, DefaultPull: PullUp, AltFunctions: [6]string{"I2C1_SDA", "SA3", "LCD_VSYNC", "", "", ""}},
+	{Name: "GPIO3", Number: 3, DefaultPull: PullUp, AltFunctions: [6]string{"I2C1_SCL", "SA2", "LCD_HSYNC", "", "", ""}},
+	{Name: "GPIO4", Number: 4, DefaultPull: PullDown, AltFunctions: [6]string{"GPCLK0", "SA1", "DPI_D0", "", "", "ARM_TDI"}},
+	{Name: "GPIO5", Number: 5, DefaultPull: PullDown, AltFunctions: [6]string{"GPCLK1", "SA0", "DPI_D1", "", "", "ARM_TDO"}},
+	{Name: "GPIO6", Number: 6, DefaultPull: PullDown, AltFunctions: [6]string{"GPCLK2", "SOE_N", "DPI_D2", "", "", "ARM_TCK"}},
+	{Name: "GPIO7", Number: 7, DefaultPull: PullUp, AltFunctions: [6]string{"SPI0_CE1_N", "SWE_N", "DPI_D3", "", "", ""}},
+	{Name: "GPIO8", Number: 8, DefaultPull: PullUp, AltFunctions: [6]string{"SPI0_CE0_N", "SD0", "DPI_D4", "", "", ""}},
+	{Name: "GPIO9", Number: 9, DefaultPull: PullDown, AltFunctions: [6]string{"SPI0_MISO", "SD1", "DPI_D5", "", "", ""}},
+	{Name: "GPIO10", Number: 10, DefaultPull: PullDown, AltFunctions: [6]string{"SPI0_MOSI", "SD2", "DPI_D6", "", "", ""}},
+	{Name: "GPIO11", Number: 11, DefaultPull: PullDown, AltFunctions: [6]string{"SPI0_SCLK", "SD3", "DPI_D7", "", "", ""}},
+	{Name: "GPIO12", Number: 12, DefaultPull: PullDown, AltFunctions: [6]string{"PWM0", "SD4", "DPI_D8", "", "", "ARM_TMS"}},
+	{Name: "GPIO13", Number: 13, DefaultPull: PullDown, AltFunctions: [6]string{"PWM1", "SD5", "DPI_D9", "", "", "ARM_TCLK"}},
+	{Name: "GPIO14", Number: 14, DefaultPull: PullDown, AltFunctions: [6]string{"UART0_TXD", "SD6", "DPI_D10", "", "", "UART1_TXD"}},
+	{Name: "GPIO15", Number: 15, DefaultPull: PullDown, AltFunctions: [6]string{"UART0_RXD", "SD7", "DPI_D11", "", "", "UART1_RXD"}},
+	{Name: "GPIO16", Number: 16, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD8", "DPI_D12", "UART0_CTS", "", "UART1_CTS"}},
+	{Name: "GPIO17", Number: 17, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD9", "DPI_D13", "UART0_RTS", "", "UART1_RTS"}},
+	{Name: "GPIO18", Number: 18, DefaultPull: PullDown, AltFunctions: [6]string{"PCM_CLK", "SD10", "DPI_D14", "BSCSL_SDA_MOSI", "", "PWM0"}},
+	{Name: "GPIO19", Number: 19, DefaultPull: PullDown, AltFunctions: [6]string{"PCM_FS", "SD11", "DPI_D15", "BSCSL_SCL_SCLK", "", "PWM1"}},
+	{Name: "GPIO20", Number: 20, DefaultPull: PullDown, AltFunctions: [6]string{"PCM_DIN", "SD12", "DPI_D16", "BSCSL_MISO", "", "GPCLK0"}},
+	{Name: "GPIO21", Number: 21, DefaultPull: PullDown, AltFunctions: [6]string{"PCM_DOUT", "SD13", "DPI_D17", "BSCSL_CE_N", "", "GPCLK1"}},
+	{Name: "GPIO22", Number: 22, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD14", "DPI_D18", "SD1_CLK", "", "ARM_TRST"}},
+	{Name: "GPIO23", Number: 23, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD15", "DPI_D19", "SD1_CMD", "", "ARM_RTCK"}},
+	{Name: "GPIO24", Number: 24, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD16", "DPI_D20", "SD1_DAT0", "", "ARM_TDO"}},
+	{Name: "GPIO25", Number: 25, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD17", "DPI_D21", "SD1_DAT1", "", "ARM_TCK"}},
+	{Name: "GPIO26", Number: 26, DefaultPull: PullDown, AltFunctions: [6]string{"", "", "DPI_D22", "SD1_DAT2", "", "ARM_TDI"}},
+	{Name: "GPIO27", Number: 27, DefaultPull: PullDown, AltFunctions: [6]string{"", "", "DPI_D23", "SD1_DAT3", "", "ARM_TMS"}},
+	{Name: "GPIO28", Number: 28, DefaultPull: PullNone, AltFunctions: [6]string{"I2C0_SDA", "SA5", "PCM_CLK", "", "", ""}},
+	{Name: "GPIO29", Number: 29, DefaultPull: PullNone, AltFunctions: [6]string{"I2C0_SCL", "SA4", "PCM_FS", "", "", ""}},
+	{Name: "GPIO30", Number: 30, DefaultPull: PullDown, AltFunctions: [6]string{"", "SA3", "PCM_DIN", "UART0_CTS", "", "UART1_CTS"}},
+	{Name: "GPIO31", Number: 31, DefaultPull: PullDown, AltFunctions: [6]string{"", "SA2", "PCM_DOUT", "UART0_RTS", "", "UART1_RTS"}},
+	{Name: "GPIO32", Number: 32, DefaultPull: PullDown, AltFunctions: [6]string{"GPCLK0", "SA1", "", "UART0_TXD", "", "UART1_TXD"}},
+	{Name: "GPIO33", Number: 33, DefaultPull: PullDown, AltFunctions: [6]string{"", "SA0", "", "UART0_RXD", "", "UART1_RXD"}},
+	{Name: "GPIO34", Number: 34, DefaultPull: PullUp, AltFunctions: [6]string{"GPCLK0", "SOE_N", "", "", "", ""}},
+	{Name: "GPIO35", Number: 35, DefaultPull: PullUp, AltFunctions: [6]string{"", "SWE_N", "", "", "", ""}},
+	{Name: "GPIO36", Number: 36, DefaultPull: PullUp, AltFunctions: [6]string{"", "SD0", "UART0_TXD", "", "", ""}},
+	{Name: "GPIO37", Number: 37, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD1", "UART0_RXD", "", "", ""}},
+	{Name: "GPIO38", Number: 38, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD2", "UART0_RTS", "", "", ""}},
+	{Name: "GPIO39", Number: 39, DefaultPull: PullDown, AltFunctions: [6]string{"", "SD3", "UART0_CTS", "", "", ""}},
+	{Name: "GPIO40", Number: 40, DefaultPull: PullDown, AltFunctions: [6]string{"PWM0", "SD4", "", "SPI2_MISO", "", "UART1_TXD"}},
+	{Name: "GPIO41", Number: 41, DefaultPull: PullDown, AltFunctions: [6]string{"PWM1", "SD5", "", "SPI2_MOSI", "", "UART1_RXD"}},
+	{Name: "GPIO42", Number: 42, DefaultPull: PullDown, AltFunctions: [6]string{"GPCLK1", "SD6", "", "SPI2_SCLK", "", "UART1_RTS"}},
+	{Name: "GPIO43", Number: 43, DefaultPull: PullDown, AltFunctions: [6]string{"GPCLK2", "SD7", "", "SPI2_CE0_N", "", "UART1_CTS"}},
+	{Name: "GPIO44", Number: 44, DefaultPull: PullNone, AltFunctions: [6]string{"GPCLK1", "SDA0", "SDA1", "SPI2_CE1_N", "", ""}},
+	{Name: "GPIO45", Number: 45, DefaultPull: PullNone, AltFunctions: [6]string{"PWM1", "SCL0", "SCL1", "SPI2_CE2_N", "", ""}},
+	{Name: "GPIO46", Number: 46, DefaultPull: PullUp, AltFunctions: [6]string{"", "", "", "", "", ""}},
+	{Name: "GPIO47", Number: 47, DefaultPull: PullUp, AltFunctions: [6]string{"SD_CARD_CMD", "", "", "", "", ""}},
+	{Name: "GPIO48", Number: 48, DefaultPull: PullUp, AltFunctions: [6]string{"SD_CARD_CLK", "", "", "", "", ""}},
+	{Name: "GPIO49", Number: 49, DefaultPull: PullUp, AltFunctions: [6]string{"SD_CARD_DAT0", "", "", "", "", ""}},
+	{Name: "GPIO50", Number: 50, DefaultPull: PullUp, AltFunctions: [6]string{"SD_CARD_DAT1", "", "", "", "", ""}},
+	{Name: "GPIO51", Number: 51, DefaultPull: PullUp, AltFunctions: [6]string{"SD_CARD_DAT2", "", "", "", "", ""}},
+	{Name: "GPIO52", Number: 52, DefaultPull: PullUp, AltFunctions: [6]string{"SD_CARD_DAT3", "", "", "", "", ""}},
+	{Name: "GPIO53", Number: 53, DefaultPull: PullUp, AltFunctions: [6]string{"", "", "", "", "", ""}},
+}
+
+var pinsByName map[string]*Pin
+
+func init() {
+	pinsByName = make(map[string]*Pin, len(pinTable))
+	for i := range pinTable {
+		pinsByName[pinTable[i].Name] = &pinTable[i]
+	}
+}
+
+// ByName returns the GpioPin with the given conventional name, such as
+// "GPIO14", or nil if name is not a recognized BCM2835 GPIO.
+func ByName(name string) GpioPin {
+	pin, ok := pinsByName[name]
+	if !ok {
+		return nil
+	}
+	return gpioPin(pin.Number)
+}
+
+// All returns the GpioPin for every GPIO the BCM2835 exposes, in ascending
+// numeric order.
+func All() []GpioPin {
+	pins := make([]GpioPin, len(pinTable))
+	for i, p := range pinTable {
+		pins[i] = gpioPin(p.Number)
+	}
+	return pins
+}
+
+// Describe returns the Pin descriptor for this GPIO, giving its
+// conventional name, default pull, and alternate function table. It
+// returns the zero Pin if this GpioPin's number is outside the 0-53 range
+// the BCM2835 actually exposes.
+func (pin gpioPin) Describe() Pin {
+	n := int(pin)
+	if n < 0 || n >= len(pinTable) {
+		return Pin{}
+	}
+	return pinTable[n]
+}
@@ -0,0 +1,311 @@
+// +build linux
+
+package bcm2835
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/apparentlymart/go-gpio/gpio"
+)
+
+// A Pulse describes one segment of a PulseStream: the GPIO level to hold
+// and for how long, before moving on to the next pulse in the sequence.
+type Pulse struct {
+	Level    gpio.Value
+	Duration time.Duration
+}
+
+// An RGB is one pixel's color, as consumed by EncodeWS2812.
+type RGB struct {
+	R, G, B uint8
+}
+
+// A Handle identifies a pulse sequence previously submitted to a
+// PulseStream, for use with Wait and Stop.
+type Handle uint32
+
+// PulseStream emits precisely-timed sequences of GPIO level changes using
+// the BCM2835 DMA controller, so that timing accuracy is not at the mercy
+// of Go's goroutine scheduler or garbage collector pauses. This is the
+// approach needed to drive protocols such as WS2812 LED strips or RC
+// servos, where pulse widths must be held to within a few hundred
+// nanoseconds.
+//
+// The PWM peripheral is used only as a pacing clock for the DMA chain and
+// is reconfigured as a side effect of Submit; callers that also need
+// PWMChannel for its own sake should not use both at once.
+type PulseStream interface {
+	// Submit encodes pulses into a chain of DMA control blocks and starts
+	// it running on GPIO pin, returning a Handle that can be passed to
+	// Wait or Stop.
+	Submit(pin int, pulses []Pulse) (Handle, error)
+
+	// Wait blocks until the pulse sequence identified by h has finished
+	// playing out.
+	Wait(h Handle) error
+
+	// Stop halts the DMA engine immediately, truncating the pulse
+	// sequence identified by h if it has not already finished.
+	Stop(h Handle) error
+
+	// Close releases the VideoCore mailbox interface opened by the
+	// Manager's PulseStream method. Any handle still in flight should be
+	// stopped first; Close does not itself halt the DMA engine.
+	Close() error
+}
+
+const (
+	dmaBase       = peripheralBase + 0x007000
+	dmaChannel    = 5
+	dmaChannelLen = 0x100
+
+	mailboxMemAllocTag = 0x0003000C
+	mailboxMemLockTag  = 0x0003000D
+	mailboxMemFreeTag  = 0x0003000E
+
+	// MEM_FLAG_DIRECT | MEM_FLAG_COHERENT, per the VideoCore mailbox
+	// interface documentation; this gives us an uncached mapping so CPU
+	// writes are visible to the DMA engine without explicit flushing.
+	mailboxMemFlags = 0x0C
+
+	// IOCTL_MBOX_PROPERTY, as defined by the vcio kernel driver:
+	// _IOWR(100, 0, char*), whose size field is the size of a pointer:
+	// 8 bytes in a 64-bit process and 4 bytes in a 32-bit one.
+	iocMboxProperty = 0xC0006400 | unsafe.Sizeof(uintptr(0))<<16
+)
+
+// Word offsets into a DMA channel's register block.
+const (
+	dmaCsOffset       = 0x00 / 4
+	dmaConblkAdOffset = 0x04 / 4
+)
+
+const (
+	dmaCsActive = 1 << 0
+	dmaCsReset  = 1 << 31
+)
+
+// dmaControlBlock mirrors the BCM2835 DMA control block layout exactly, so
+// that it can be written directly into DMA-visible memory.
+type dmaControlBlock struct {
+	transferInfo   uint32
+	sourceAddr     uint32
+	destAddr       uint32
+	transferLen    uint32
+	stride         uint32
+	nextConblkAddr uint32
+	reserved       [2]uint32
+}
+
+const (
+	dmaTiPermapPWM = 5 << 16 // pace on PWM peripheral's DREQ
+	dmaTiDestDreq  = 1 << 6  // gate DEST writes on the PERMAP peripheral's DREQ
+	dmaTiWaitResp  = 1 << 3  // wait for a write response before the next transfer
+)
+
+// busAddrOf converts one of this package's /dev/mem physical addresses
+// (such as gpioBase or pwmBase) into the bus address the DMA controller
+// expects, per the BCM2835 peripherals manual's address map.
+func busAddrOf(physAddr int) uint32 {
+	return uint32(physAddr-peripheralBase) + 0x7E000000
+}
+
+type pulseStream struct {
+	mbox *os.File
+
+	mu      sync.Mutex
+	handles map[Handle]*dmaAllocation
+	nextID  uint32
+}
+
+type dmaAllocation struct {
+	mboxHandle uint32
+	busAddr    uint32
+	size       uint32
+	mem        []byte // ARM-visible mapping of the same memory
+}
+
+// PulseStream opens the VideoCore mailbox interface and returns a new
+// PulseStream that uses it. Each call opens the mailbox again.
+func (mgr *manager) PulseStream() (PulseStream, error) {
+	mbox, err := os.OpenFile("/dev/vcio", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pulseStream{
+		mbox:    mbox,
+		handles: map[Handle]*dmaAllocation{},
+	}, nil
+}
+
+// Submit builds a chain of DMA control blocks that alternately write to
+// the GPSETn/GPCLRn register covering pin and consume one cycle of the PWM FIFO (used purely
+// as a timing source), then starts DMA channel 5 running the chain.
+func (s *pulseStream) Submit(pin int, pulses []Pulse) (Handle, error) {
+	if len(pulses) == 0 {
+		return 0, errors.New("pulse sequence must not be empty")
+	}
+
+	alloc, err := s.allocateDMAMemory(uint32(len(pulses)*2) * uint32(unsafe.Sizeof(dmaControlBlock{})))
+	if err != nil {
+		return 0, err
+	}
+
+	blockCount := len(pulses) * 2
+	blocks := (*[1 << 20]dmaControlBlock)(unsafe.Pointer(&alloc.mem[0]))[:blockCount:blockCount]
+
+	const cbSize = uint32(unsafe.Sizeof(dmaControlBlock{}))
+	const reservedOffset = uint32(unsafe.Offsetof(dmaControlBlock{}.reserved))
+
+	configurePacingClock()
+
+	setMask := uint32(1) << uint(pin%32)
+	bank := pin / 32
+	for i, p := range pulses {
+		gpioCb := &blocks[i*2]
+		paceCb := &blocks[i*2+1]
+		gpioAddr := alloc.busAddr + uint32(i*2)*cbSize
+		paceAddr := gpioAddr + cbSize
+
+		// reserved[0] is not used by the DMA engine, so we borrow it as
+		// the constant source word the GPIO control block writes to
+		// GPSETn or GPCLRn for this pulse's level.
+		gpioCb.reserved[0] = setMask
+		gpioCb.transferInfo = dmaTiWaitResp
+		gpioCb.transferLen = 4
+		gpioCb.sourceAddr = gpioAddr + reservedOffset
+		if p.Level == gpio.High {
+			gpioCb.destAddr = busAddrOf(gpioBase + (gpset0Offset+bank)*4) // GPSETn
+		} else {
+			gpioCb.destAddr = busAddrOf(gpioBase + (gpclr0Offset+bank)*4) // GPCLRn
+		}
+		gpioCb.nextConblkAddr = paceAddr
+
+		// The pacing block drains one PWM FIFO word per pacingClockHz
+		// tick, holding up the chain (via DREQ) for approximately
+		// p.Duration before the next pulse's level change takes effect.
+		cycles := uint32(p.Duration.Seconds()*pacingClockHz) + 1
+		paceCb.transferInfo = dmaTiPermapPWM | dmaTiDestDreq | dmaTiWaitResp
+		paceCb.sourceAddr = gpioAddr + reservedOffset // fixed, unused value
+		paceCb.destAddr = busAddrOf(pwmBase + pwmFifoOffset*4) // PWM_FIFO
+		paceCb.transferLen = cycles * 4
+
+		if i+1 < len(pulses) {
+			paceCb.nextConblkAddr = gpioAddr + 2*cbSize
+		} else {
+			paceCb.nextConblkAddr = 0
+		}
+	}
+
+	if err := s.startDMA(alloc.busAddr); err != nil {
+		s.freeDMAMemory(alloc)
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	h := Handle(s.nextID)
+	s.handles[h] = alloc
+	s.mu.Unlock()
+
+	return h, nil
+}
+
+// Wait polls DMA channel 5's ACTIVE bit until the chain started by the
+// Submit call identified by h has finished, then frees the DMA memory
+// allocated for it.
+func (s *pulseStream) Wait(h Handle) error {
+	alloc := s.lookup(h)
+	if alloc == nil {
+		return errors.New("unknown pulse stream handle")
+	}
+
+	for dmaMem32[dmaCsOffset]&dmaCsActive != 0 {
+		shortWait()
+	}
+
+	s.mu.Lock()
+	delete(s.handles, h)
+	s.mu.Unlock()
+
+	return s.freeDMAMemory(alloc)
+}
+
+// Stop halts DMA channel 5 immediately, truncating playback if it has not
+// already finished.
+func (s *pulseStream) Stop(h Handle) error {
+	alloc := s.lookup(h)
+	if alloc == nil {
+		return errors.New("unknown pulse stream handle")
+	}
+
+	dmaMem32[dmaCsOffset] = dmaCsReset
+
+	s.mu.Lock()
+	delete(s.handles, h)
+	s.mu.Unlock()
+
+	return s.freeDMAMemory(alloc)
+}
+
+// Close releases the /dev/vcio file descriptor opened by PulseStream.
+func (s *pulseStream) Close() error {
+	return s.mbox.Close()
+}
+
+func (s *pulseStream) lookup(h Handle) *dmaAllocation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handles[h]
+}
+
+func (s *pulseStream) startDMA(busAddr uint32) error {
+	dmaMem32[dmaCsOffset] = dmaCsReset
+	for dmaMem32[dmaCsOffset]&dmaCsReset != 0 {
+		shortWait()
+	}
+
+	dmaMem32[dmaConblkAdOffset] = busAddr
+	dmaMem32[dmaCsOffset] = dmaCsActive
+
+	return nil
+}
+
+// EncodeWS2812 converts a sequence of pixel colors into the Pulse sequence
+// needed to shift them out to a WS2812-class addressable LED strip
+// attached to pin, using the 0.4µs/0.8µs high times (each followed by a
+// 0.85µs/0.45µs low time) that distinguish a 0 bit from a 1 bit in that
+// protocol.
+func EncodeWS2812(pin int, colors []RGB) []Pulse {
+	const (
+		zeroHigh = 400 * time.Nanosecond
+		zeroLow  = 850 * time.Nanosecond
+		oneHigh  = 800 * time.Nanosecond
+		oneLow   = 450 * time.Nanosecond
+	)
+
+	pulses := make([]Pulse, 0, len(colors)*24)
+	for _, c := range colors {
+		// WS2812 wants G, R, B, most significant bit first.
+		for _, b := range [3]uint8{c.G, c.R, c.B} {
+			for bit := 7; bit >= 0; bit-- {
+				if b&(1<<uint(bit)) != 0 {
+					pulses = append(pulses,
+						Pulse{gpio.High, oneHigh},
+						Pulse{gpio.Low, oneLow})
+				} else {
+					pulses = append(pulses,
+						Pulse{gpio.High, zeroHigh},
+						Pulse{gpio.Low, zeroLow})
+				}
+			}
+		}
+	}
+
+	return pulses
+}
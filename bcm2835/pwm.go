@@ -0,0 +1,306 @@
+// +build linux
+
+package bcm2835
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	pwmBase   = peripheralBase + 0x20C000
+	pwmLength = 4096
+
+	clockBase   = peripheralBase + 0x101000
+	clockLength = 4096
+)
+
+// Word offsets into the PWM peripheral's register block.
+const (
+	pwmCtlOffset  = 0x00 / 4
+	pwmStaOffset  = 0x04 / 4
+	pwmDmacOffset = 0x08 / 4
+	pwmRng1Offset = 0x10 / 4
+	pwmDat1Offset = 0x14 / 4
+	pwmFifoOffset = 0x18 / 4
+	pwmRng2Offset = 0x20 / 4
+	pwmDat2Offset = 0x24 / 4
+)
+
+// Bits within PWM_CTL relevant to driving channel 1 from the FIFO rather
+// than DAT1, as used by PulseStream to pace DMA transfers.
+const pwmCtlUsef1 = 1 << 5
+
+// Bits within PWM_DMAC. DREQ/PANIC thresholds are set conservatively low
+// since PulseStream only needs the FIFO's DREQ signal, not high throughput.
+const (
+	pwmDmacEnab        = 1 << 31
+	pwmDmacDefaultReqs = (7 << 0) | (7 << 8) // PANIC and DREQ thresholds
+)
+
+// Word offsets into the clock manager's register block.
+const (
+	cmPwmCtlOffset = 0xA0 / 4
+	cmPwmDivOffset = 0xA4 / 4
+)
+
+// The clock manager requires this value in the top byte of any write to
+// CM_PWMCTL or CM_PWMDIV, as a safety measure against accidental writes.
+const clockManagerPassword = 0x5A000000
+
+// Bits within PWM_CTL. Channel 2's bits are these shifted left by 8.
+const (
+	pwmCtlPWEN1 = 1 << 0 // Enable channel 1
+	pwmCtlMSEN1 = 1 << 7 // Channel 1 mark-space mode (vs. balanced)
+	pwmCtlPWEN2 = 1 << 8
+	pwmCtlMSEN2 = 1 << 15
+)
+
+// Bits within CM_PWMCTL.
+const (
+	cmCtlEnab          = 1 << 4
+	cmCtlBusy          = 1 << 7
+	cmCtlSrcOscillator = 1 // 19.2MHz oscillator
+)
+
+var (
+	pwmMem8    []uint8
+	pwmMem32   []uint32
+	clockMem8  []uint8
+	clockMem32 []uint32
+)
+
+// PWMMode selects how a PWMChannel interprets its range and data registers.
+type PWMMode int
+
+const (
+	// PWMMarkSpace produces a conventional fixed-frequency PWM signal, with
+	// DutyCycle proportion of each period high and the remainder low.
+	PWMMarkSpace PWMMode = iota
+
+	// PWMBalanced spreads the high bits evenly across each period rather
+	// than grouping them at the start, which can reduce EMI and audible
+	// noise for some loads at the expense of jitter.
+	PWMBalanced
+)
+
+// PWMChannel controls one of the BCM2835's two hardware PWM channels, which
+// are routed to GPIO12/18 (channel 0) and GPIO13/19 (channel 1) depending on
+// which alternate function has been selected on the relevant GpioPin.
+//
+// Callers must select the appropriate alt function on the GPIO pin they
+// intend to use (via GpioPin.SetAltFunction) before the signal will actually
+// reach a pin; PWMChannel itself only configures the PWM peripheral.
+type PWMChannel interface {
+	// SetFrequency configures the PWM clock so that the channel completes
+	// one period at approximately the given frequency, in Hz. The actual
+	// frequency will be rounded to the nearest value achievable with an
+	// integer clock divisor from the 19.2MHz oscillator.
+	//
+	// Both PWM channels share a single clock, so calling SetFrequency on
+	// one channel also affects the other.
+	SetFrequency(hz float64) error
+
+	// SetDutyCycle sets the proportion of each period that the output
+	// should be high, as a value between 0 (always low) and 1 (always
+	// high).
+	SetDutyCycle(duty float64) error
+
+	// SetMode selects between mark-space and balanced output modes.
+	SetMode(mode PWMMode) error
+
+	// Start enables the channel's output.
+	Start() error
+
+	// Stop disables the channel's output, leaving it low.
+	Stop() error
+}
+
+type pwmChannel int
+
+// PWMChannel returns the PWMChannel for hardware PWM channel n, which must
+// be 0 or 1.
+func (mgr *manager) PWMChannel(n int) PWMChannel {
+	if n != 0 && n != 1 {
+		panic("PWM channel number must be 0 or 1")
+	}
+	return pwmChannel(n)
+}
+
+func (ch pwmChannel) rangeOffset() int {
+	if ch == 0 {
+		return pwmRng1Offset
+	}
+	return pwmRng2Offset
+}
+
+func (ch pwmChannel) dataOffset() int {
+	if ch == 0 {
+		return pwmDat1Offset
+	}
+	return pwmDat2Offset
+}
+
+func (ch pwmChannel) enableBit() uint32 {
+	if ch == 0 {
+		return pwmCtlPWEN1
+	}
+	return pwmCtlPWEN2
+}
+
+func (ch pwmChannel) msEnableBit() uint32 {
+	if ch == 0 {
+		return pwmCtlMSEN1
+	}
+	return pwmCtlMSEN2
+}
+
+// SetFrequency configures the shared PWM clock divisor for the requested
+// frequency, assuming a range of 1024 steps per period.
+func (ch pwmChannel) SetFrequency(hz float64) error {
+	if hz <= 0 {
+		return errors.New("PWM frequency must be positive")
+	}
+
+	const oscillatorHz = 19200000
+	const steps = 1024
+
+	// Of the two integer divisors either side of the exact one, use the
+	// one whose frequency is nearer to hz.
+	divisor := uint32(oscillatorHz / (hz * steps))
+	if divisor < 1 || oscillatorHz/(steps*float64(divisor))-hz > hz-oscillatorHz/(steps*float64(divisor+1)) {
+		divisor++
+	}
+	if divisor < 1 {
+		divisor = 1
+	}
+	if divisor > 4095 {
+		divisor = 4095
+	}
+
+	setClockDivisor(divisor)
+	pwmMem32[ch.rangeOffset()] = steps
+
+	return nil
+}
+
+// SetDutyCycle sets DAT relative to the channel's current RNG, so
+// SetFrequency should generally be called first.
+func (ch pwmChannel) SetDutyCycle(duty float64) error {
+	if duty < 0 || duty > 1 {
+		return errors.New("PWM duty cycle must be between 0 and 1")
+	}
+
+	rng := pwmMem32[ch.rangeOffset()]
+	pwmMem32[ch.dataOffset()] = uint32(duty * float64(rng))
+
+	return nil
+}
+
+func (ch pwmChannel) SetMode(mode PWMMode) error {
+	if mode == PWMBalanced {
+		pwmMem32[pwmCtlOffset] &= ^ch.msEnableBit()
+	} else {
+		pwmMem32[pwmCtlOffset] |= ch.msEnableBit()
+	}
+	return nil
+}
+
+func (ch pwmChannel) Start() error {
+	pwmMem32[pwmCtlOffset] |= ch.enableBit()
+	return nil
+}
+
+func (ch pwmChannel) Stop() error {
+	pwmMem32[pwmCtlOffset] &= ^ch.enableBit()
+	return nil
+}
+
+// setClockDivisor reprograms the PWM clock, which is shared between both
+// hardware PWM channels. The clock must be disabled and confirmed stopped
+// before its divisor can be changed, per the BCM2835 peripherals manual.
+func setClockDivisor(divisor uint32) {
+	clockMem32[cmPwmCtlOffset] = clockManagerPassword | 1 // kill the clock
+	for clockMem32[cmPwmCtlOffset]&cmCtlBusy != 0 {
+		// Hardware docs call for us to wait for BUSY to clear.
+		shortWait()
+	}
+
+	clockMem32[cmPwmDivOffset] = clockManagerPassword | (divisor << 12)
+	clockMem32[cmPwmCtlOffset] = clockManagerPassword | cmCtlSrcOscillator | cmCtlEnab
+}
+
+// pacingClockHz is the nominal rate at which PulseStream consumes PWM FIFO
+// words to pace its DMA chain. 19.2MHz / 2 is the closest integer divisor
+// to 10MHz that the clock manager supports.
+const pacingClockHz = 9600000
+
+// configurePacingClock sets up PWM channel 1 to drain one FIFO word per
+// pacingClockHz tick, without itself driving any GPIO pin. PulseStream's
+// DMA chain uses the resulting DREQ purely as a timing source.
+func configurePacingClock() {
+	setClockDivisor(2)
+
+	pwmMem32[pwmCtlOffset] = 0
+	pwmMem32[pwmRng1Offset] = 1 // one pacingClockHz tick drains one FIFO word
+	pwmMem32[pwmDmacOffset] = pwmDmacEnab | pwmDmacDefaultReqs
+	pwmMem32[pwmCtlOffset] = pwmCtlUsef1 | pwmCtlPWEN1
+}
+
+func mapPWMAndClock(fd int) error {
+	var err error
+
+	pwmMem8, err = syscall.Mmap(
+		fd,
+		pwmBase,
+		pwmLength,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return err
+	}
+	pwmMem32 = bytesToWords(pwmMem8)
+
+	clockMem8, err = syscall.Mmap(
+		fd,
+		clockBase,
+		clockLength,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		syscall.Munmap(pwmMem8)
+		pwmMem8 = nil
+		pwmMem32 = nil
+		return err
+	}
+	clockMem32 = bytesToWords(clockMem8)
+
+	return nil
+}
+
+// stopPWMChannels disables both hardware PWM channels' output, as well as
+// the FIFO/DMA pacing mode PulseStream puts channel 1 into, so that nothing
+// keeps driving a GPIO once the Manager is closed.
+func stopPWMChannels() {
+	pwmMem32[pwmCtlOffset] = 0
+}
+
+func unmapPWMAndClock() error {
+	stopPWMChannels()
+
+	if err := syscall.Munmap(pwmMem8); err != nil {
+		return err
+	}
+	pwmMem8 = nil
+	pwmMem32 = nil
+
+	if err := syscall.Munmap(clockMem8); err != nil {
+		return err
+	}
+	clockMem8 = nil
+	clockMem32 = nil
+
+	return nil
+}
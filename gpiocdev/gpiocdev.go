@@ -0,0 +1,287 @@
+// +build linux
+
+// Package gpiocdev provides access to Linux GPIO lines via the character
+// device interface (/dev/gpiochipN), using the GPIO v2 ioctl ABI introduced
+// in Linux 5.10. This replaces the older /sys/class/gpio sysfs interface,
+// which newer kernels (including Raspberry Pi OS "Bookworm" and later) no
+// longer provide.
+//
+// This package is concerned only with line ownership and edge detection;
+// high-frequency value reads and writes should go through a peripheral's
+// own mmap-based register access where available, since that avoids a
+// syscall per access.
+package gpiocdev
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// LineFlags selects the requested electrical and edge-detection
+// configuration for a line, as a bitmask of the GPIO_V2_LINE_FLAG_*
+// constants from the kernel's gpio v2 uAPI.
+type LineFlags uint64
+
+const (
+	LineInput       LineFlags = 1 << 2
+	LineOutput      LineFlags = 1 << 3
+	LineActiveLow   LineFlags = 1 << 1
+	LinePullUp      LineFlags = 1 << 8
+	LinePullDown    LineFlags = 1 << 9
+	LineEdgeRising  LineFlags = 1 << 4
+	LineEdgeFalling LineFlags = 1 << 5
+)
+
+// Edge identifies which transition a WaitForEdge call observed.
+type Edge int
+
+const (
+	NoEdge Edge = iota
+	RisingEdge
+	FallingEdge
+)
+
+// GPIO v2 ioctl numbers, from <linux/gpio.h>. GPIO_V2_GET_LINE_IOCTL
+// requests a line and returns an anonymous fd representing it;
+// GPIO_V2_LINE_SET_CONFIG_IOCTL reconfigures a line fd already obtained
+// that way without having to give up and re-request the line;
+// GET_VALUES/SET_VALUES read and write the requested lines' levels.
+const (
+	gpioV2GetLineIoctl       = 0xC250B407
+	gpioV2LineSetConfigIoctl = 0xC110B40D
+	gpioV2LineGetValuesIoctl = 0xC010B40E
+	gpioV2LineSetValuesIoctl = 0xC010B40F
+)
+
+const (
+	maxLines        = 64
+	consumerNameLen = 32
+	maxConfigAttrs  = 10
+)
+
+// gpioV2LineAttribute mirrors struct gpio_v2_line_attribute. value is a
+// union in the kernel (flags, a bit64 of values, or a debounce period in
+// microseconds); here it is just the raw 8 bytes, reinterpreted by callers
+// as needed.
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64
+}
+
+// gpioV2LineConfigAttribute mirrors struct gpio_v2_line_config_attribute,
+// which is the 16-byte gpioV2LineAttribute above followed by an 8-byte
+// line-selector mask, for 24 bytes total.
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+// gpioV2LineValues mirrors struct gpio_v2_line_values, used with
+// GET_VALUES_IOCTL/SET_VALUES_IOCTL.
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// gpioV2LineConfig mirrors struct gpio_v2_line_config.
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [maxConfigAttrs]gpioV2LineConfigAttribute
+}
+
+// gpioV2LineRequest mirrors struct gpio_v2_line_request.
+type gpioV2LineRequest struct {
+	offsets         [maxLines]uint32
+	consumer        [consumerNameLen]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+// gpioV2LineEvent mirrors struct gpio_v2_line_event, as read directly from
+// a requested line's fd once edge detection has been enabled on it.
+type gpioV2LineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+// Node represents ownership of one GPIO line obtained via its chip's
+// character device.
+type Node interface {
+	// Number returns the GPIO number this Node was requested for.
+	Number() int
+
+	// WaitForEdge blocks until an edge configured on this line (via the
+	// flags passed to RequestLine) occurs, or until timeout elapses, in
+	// which case it returns NoEdge and no error.
+	WaitForEdge(timeout time.Duration) (Edge, error)
+
+	// Value reads this line's current level via GPIO_V2_LINE_GET_VALUES_IOCTL.
+	//
+	// This is a syscall per call; a GpioPin's own mmap-based Value method
+	// should be preferred for anything latency-sensitive. Value exists on
+	// Node mainly so that lines without mmap-backed register access (such
+	// as GPIOs on a different gpiochip) can still be read.
+	Value() (bool, error)
+
+	// SetValue writes this line's level via GPIO_V2_LINE_SET_VALUES_IOCTL.
+	// The same latency caveat as Value applies.
+	SetValue(high bool) error
+
+	// Close releases the line, allowing another process (or another
+	// RequestLine call) to request it.
+	Close() error
+}
+
+type node struct {
+	number int
+	lineFd int
+}
+
+// RequestLine opens chipPath (typically "/dev/gpiochip0") and requests
+// exclusive ownership of the line for the given GPIO number, configured
+// according to flags.
+func RequestLine(chipPath string, number int, flags LineFlags) (Node, error) {
+	chip, err := os.OpenFile(chipPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer chip.Close()
+
+	var req gpioV2LineRequest
+	req.offsets[0] = uint32(number)
+	req.numLines = 1
+	req.config.flags = uint64(flags)
+	copy(req.consumer[:], "go-bcm2835io")
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		chip.Fd(),
+		uintptr(gpioV2GetLineIoctl),
+		uintptr(unsafe.Pointer(&req)),
+	)
+	if errno != 0 {
+		return nil, fmt.Errorf("requesting GPIO line %d: %w", number, errno)
+	}
+
+	return &node{number: number, lineFd: int(req.fd)}, nil
+}
+
+func (n *node) Number() int {
+	return n.number
+}
+
+// pollfd mirrors the C struct pollfd, since the standard syscall package
+// does not expose poll(2) directly on this platform.
+type pollfd struct {
+	fd      int32
+	events  int16
+	revents int16
+}
+
+const pollIn = 0x0001
+
+// poll waits for fd to become readable using ppoll(2) rather than poll(2),
+// since SYS_POLL is not implemented on 64-bit kernels (including arm64,
+// one of this package's two target architectures).
+func poll(fd int, timeout time.Duration) (ready bool, err error) {
+	fds := [1]pollfd{{fd: int32(fd), events: pollIn}}
+
+	var timeoutPtr *syscall.Timespec
+	if timeout >= 0 {
+		ts := syscall.NsecToTimespec(timeout.Nanoseconds())
+		timeoutPtr = &ts
+	}
+
+	n, _, errno := syscall.Syscall6(
+		syscall.SYS_PPOLL,
+		uintptr(unsafe.Pointer(&fds[0])),
+		uintptr(1),
+		uintptr(unsafe.Pointer(timeoutPtr)),
+		0,
+		0,
+		0,
+	)
+	if errno != 0 {
+		return false, errno
+	}
+
+	return n > 0, nil
+}
+
+func (n *node) WaitForEdge(timeout time.Duration) (Edge, error) {
+	ready, err := poll(n.lineFd, timeout)
+	if err != nil {
+		return NoEdge, err
+	}
+	if !ready {
+		return NoEdge, nil
+	}
+
+	var ev gpioV2LineEvent
+	buf := (*[unsafe.Sizeof(ev)]byte)(unsafe.Pointer(&ev))[:]
+	if _, err := syscall.Read(n.lineFd, buf); err != nil {
+		return NoEdge, err
+	}
+
+	switch ev.id {
+	case 1:
+		return RisingEdge, nil
+	case 2:
+		return FallingEdge, nil
+	default:
+		return NoEdge, errors.New("unrecognized gpio_v2_line_event id")
+	}
+}
+
+func (n *node) Value() (bool, error) {
+	values := gpioV2LineValues{mask: 1}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		uintptr(n.lineFd),
+		uintptr(gpioV2LineGetValuesIoctl),
+		uintptr(unsafe.Pointer(&values)),
+	)
+	if errno != 0 {
+		return false, errno
+	}
+
+	return values.bits&1 != 0, nil
+}
+
+func (n *node) SetValue(high bool) error {
+	values := gpioV2LineValues{mask: 1}
+	if high {
+		values.bits = 1
+	}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		uintptr(n.lineFd),
+		uintptr(gpioV2LineSetValuesIoctl),
+		uintptr(unsafe.Pointer(&values)),
+	)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+func (n *node) Close() error {
+	return syscall.Close(n.lineFd)
+}